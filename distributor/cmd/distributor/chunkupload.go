@@ -0,0 +1,208 @@
+package main
+
+import (
+	"crypto/md5" //nolint:gosec // used for client-chunk integrity checking, not for security.
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"sync"
+
+	log "github.com/obalunenko/logger"
+)
+
+// headerFullFileMD5 carries the MD5 of the whole, reassembled file so the
+// server can detect on-wire corruption before the shard fan-out.
+const headerFullFileMD5 = "X-File-MD5"
+
+// chunkEnvelope is the JSON body a client sends for each part of a
+// client-sliced upload.
+type chunkEnvelope struct {
+	FileID   string   `json:"fileId"`
+	FileName string   `json:"fileName"`
+	FileKeys []string `json:"fileKeys"`
+	FileKey  string   `json:"fileKey"`
+	Order    int      `json:"order"`
+	Data     []byte   `json:"data"`
+}
+
+// pendingUpload tracks the parts received so far for one in-progress
+// client-chunked upload, plus the fileKeys it was first announced with so a
+// later envelope for the same fileId can't change the expected part count
+// out from under it.
+type pendingUpload struct {
+	fileKeys []string
+	parts    map[int][]byte
+}
+
+// pendingChunks holds parts of in-progress client-chunked uploads, keyed by
+// fileId, until every index named in fileKeys has arrived.
+var pendingChunks = struct {
+	mu      sync.Mutex
+	uploads map[string]*pendingUpload
+}{
+	uploads: make(map[string]*pendingUpload),
+}
+
+// chunkUploadHandler implements the client-driven chunked upload workflow:
+// the client slices a file itself and POSTs one chunkEnvelope per part. Each
+// part is verified against its own MD5 before being buffered; once all parts
+// for a fileId have arrived they are assembled in order, the whole file's
+// MD5 is checked against the X-File-MD5 header when the request carries one
+// (without it the assembled file is not checked), and the result is handed
+// to the same shard fan-out used by uploadHandler.
+func (a *app) chunkUploadHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "Only POST is supported", http.StatusMethodNotAllowed)
+
+		return
+	}
+
+	var env chunkEnvelope
+
+	if err := json.NewDecoder(r.Body).Decode(&env); err != nil {
+		http.Error(w, "Failed to decode chunk envelope", http.StatusBadRequest)
+
+		return
+	}
+
+	if env.FileID == "" || len(env.FileKeys) == 0 {
+		http.Error(w, "fileId and fileKeys are required", http.StatusBadRequest)
+
+		return
+	}
+
+	if env.Order < 0 || env.Order >= len(env.FileKeys) {
+		http.Error(w, "order out of range for fileKeys", http.StatusBadRequest)
+
+		return
+	}
+
+	sum := md5.Sum(env.Data) //nolint:gosec // integrity checking, not for security.
+
+	if hex.EncodeToString(sum[:]) != env.FileKey {
+		http.Error(w, "chunk checksum mismatch", http.StatusBadRequest)
+
+		return
+	}
+
+	if env.FileKeys[env.Order] != env.FileKey {
+		http.Error(w, "fileKey does not match fileKeys at order", http.StatusBadRequest)
+
+		return
+	}
+
+	log.WithFields(r.Context(), log.Fields{
+		"file_id": env.FileID,
+		"order":   env.Order,
+	}).Info("Received file chunk")
+
+	assembled, complete, err := bufferChunk(env)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusConflict)
+
+		return
+	}
+
+	if !complete {
+		w.WriteHeader(http.StatusAccepted)
+
+		return
+	}
+
+	fullSum := md5.Sum(assembled) //nolint:gosec // integrity checking, not for security.
+
+	if want := r.Header.Get(headerFullFileMD5); want != "" && hex.EncodeToString(fullSum[:]) != want {
+		http.Error(w, "assembled file checksum mismatch", http.StatusBadRequest)
+
+		return
+	}
+
+	resourceID, err := a.distributeFile(r.Context(), env.FileName, assembled)
+	if err != nil {
+		http.Error(w, "Failed to upload file to storage", http.StatusInternalServerError)
+
+		return
+	}
+
+	log.WithFields(r.Context(), log.Fields{
+		"file_name":   env.FileName,
+		"resource_id": resourceID,
+	}).Info("Chunked file uploaded successfully")
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusCreated)
+
+	resp := uploadResponse{
+		Resource: resourceID,
+		Checksum: hex.EncodeToString(fullSum[:]),
+	}
+
+	if err := json.NewEncoder(w).Encode(resp); err != nil {
+		fmt.Printf("Failed to encode response: %v \n", err)
+	}
+}
+
+// bufferChunk stores env's part and, once every index in the fileKeys first
+// announced for env.FileID has arrived, returns the parts concatenated in
+// order and complete=true. The fileId's buffered parts are discarded once
+// the file is assembled. It returns an error if env declares a different
+// fileKeys than the first chunk seen for this fileId did, since trusting a
+// later, possibly mismatched count could assemble a wrong-length file.
+func bufferChunk(env chunkEnvelope) (data []byte, complete bool, err error) {
+	pendingChunks.mu.Lock()
+	defer pendingChunks.mu.Unlock()
+
+	upload, ok := pendingChunks.uploads[env.FileID]
+	if !ok {
+		upload = &pendingUpload{
+			fileKeys: env.FileKeys,
+			parts:    make(map[int][]byte, len(env.FileKeys)),
+		}
+		pendingChunks.uploads[env.FileID] = upload
+	}
+
+	if !equalFileKeys(upload.fileKeys, env.FileKeys) {
+		return nil, false, fmt.Errorf("fileKeys for %q does not match the first chunk received for it", env.FileID)
+	}
+
+	upload.parts[env.Order] = env.Data
+
+	if len(upload.parts) != len(upload.fileKeys) {
+		return nil, false, nil
+	}
+
+	assembled := make([]byte, 0, totalSize(upload.parts))
+
+	for i := range upload.fileKeys {
+		assembled = append(assembled, upload.parts[i]...)
+	}
+
+	delete(pendingChunks.uploads, env.FileID)
+
+	return assembled, true, nil
+}
+
+func equalFileKeys(a, b []string) bool {
+	if len(a) != len(b) {
+		return false
+	}
+
+	for i := range a {
+		if a[i] != b[i] {
+			return false
+		}
+	}
+
+	return true
+}
+
+func totalSize(parts map[int][]byte) int {
+	var size int
+
+	for _, p := range parts {
+		size += len(p)
+	}
+
+	return size
+}
@@ -0,0 +1,452 @@
+package main
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"errors"
+	"fmt"
+	"hash"
+	"io"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/obalunenko/file-distributor/distributor/internal/metadata"
+	log "github.com/obalunenko/logger"
+)
+
+// checksumTrailer carries whether the streamed bytes matched the resource's
+// whole-file digest, reported as a trailer since that digest can only be
+// known once the last byte has gone out.
+const checksumTrailer = "X-Checksum-Verified"
+
+// errTooManyMissingShards is returned when more shards are unavailable than
+// the resource's parity can reconstruct.
+var errTooManyMissingShards = errors.New("too many missing shards to reconstruct file")
+
+// downloadHandler streams a previously uploaded file back to the client. In
+// the common case it copies each data shard straight from its storage
+// server to the response writer, never holding more than one shard in
+// memory; it only falls back to buffering the whole (sub-)range in memory
+// when a shard is missing and has to be reconstructed via erasure coding.
+// It supports single-range "Range: bytes=start-end" requests.
+func (a *app) downloadHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "Only GET is supported", http.StatusMethodNotAllowed)
+
+		return
+	}
+
+	resourceID := r.URL.Query().Get("resource_id")
+	if resourceID == "" {
+		http.Error(w, "Resource ID is required", http.StatusBadRequest)
+
+		return
+	}
+
+	log.WithFields(r.Context(), log.Fields{
+		"resource_id": resourceID,
+	}).Info("Received download request")
+
+	resource, err := a.meta.GetResource(r.Context(), resourceID)
+	if err != nil {
+		if metadata.IsNotFound(err) {
+			http.Error(w, "File not found", http.StatusNotFound)
+
+			return
+		}
+
+		http.Error(w, "Failed to look up resource", http.StatusInternalServerError)
+
+		return
+	}
+
+	start, end, partial, err := parseRange(r.Header.Get("Range"), resource.OriginalSize)
+	if err != nil {
+		w.Header().Set("Content-Range", fmt.Sprintf("bytes */%d", resource.OriginalSize))
+		http.Error(w, err.Error(), http.StatusRequestedRangeNotSatisfiable)
+
+		return
+	}
+
+	body, err := a.openRange(resource, start, end)
+	if err != nil {
+		log.WithError(r.Context(), err).Error("Failed to open file range for download")
+
+		http.Error(w, "Failed to get file chunk", http.StatusInternalServerError)
+
+		return
+	}
+
+	defer func() {
+		if cerr := body.Close(); cerr != nil {
+			fmt.Printf("Failed to close download body: %v \n", cerr)
+		}
+	}()
+
+	w.Header().Set("Accept-Ranges", "bytes")
+	w.Header().Set("Content-Disposition", fmt.Sprintf("attachment; filename=%s", resource.FileName))
+	w.Header().Set("Content-Type", "application/octet-stream")
+
+	// A range request can't be checked against the whole-file digest (it
+	// never sees all the bytes), so only full downloads are hashed as they
+	// stream and reported via a trailer; that requires leaving Content-Length
+	// unset so the response is chunked.
+	var hasher hash.Hash
+
+	var stream io.Reader = body
+
+	if partial {
+		w.Header().Set("Content-Length", strconv.FormatUint(end-start, 10))
+		w.Header().Set("Content-Range", fmt.Sprintf("bytes %d-%d/%d", start, end-1, resource.OriginalSize))
+		w.WriteHeader(http.StatusPartialContent)
+	} else {
+		hasher = sha256.New()
+		stream = io.TeeReader(body, hasher)
+
+		w.Header().Set("Trailer", checksumTrailer)
+		w.WriteHeader(http.StatusOK)
+	}
+
+	flusher, canFlush := w.(http.Flusher)
+
+	written, err := copyFlushing(w, stream, flusher, canFlush)
+	if err != nil {
+		log.WithFields(r.Context(), log.Fields{
+			"resource_id": resourceID,
+			"written":     written,
+			"error":       err,
+		}).Error("Download interrupted while streaming shards")
+
+		return
+	}
+
+	if hasher != nil {
+		verified := bytes.Equal(hasher.Sum(nil), resource.WholeSHA256[:])
+
+		w.Header().Set(http.TrailerPrefix+checksumTrailer, strconv.FormatBool(verified))
+
+		if !verified {
+			log.WithFields(r.Context(), log.Fields{
+				"resource_id": resourceID,
+			}).Error("Whole-file checksum mismatch while streaming download")
+
+			return
+		}
+	}
+
+	log.WithFields(r.Context(), log.Fields{
+		"resource_id": resourceID,
+	}).Info("File downloaded successfully")
+}
+
+// copyFlushing behaves like io.Copy but flushes w after every underlying
+// write when possible, so a client streaming a large download sees bytes as
+// they arrive instead of waiting for internal buffers to fill.
+func copyFlushing(w io.Writer, r io.Reader, flusher http.Flusher, canFlush bool) (int64, error) {
+	if !canFlush {
+		return io.Copy(w, r)
+	}
+
+	buf := make([]byte, 32*1024)
+
+	var written int64
+
+	for {
+		n, readErr := r.Read(buf)
+		if n > 0 {
+			if _, err := w.Write(buf[:n]); err != nil {
+				return written, err
+			}
+
+			written += int64(n)
+
+			flusher.Flush()
+		}
+
+		if readErr != nil {
+			if errors.Is(readErr, io.EOF) {
+				return written, nil
+			}
+
+			return written, readErr
+		}
+	}
+}
+
+// parseRange parses a single "bytes=start-end" Range header against a
+// resource of the given total size. It returns the full [0, total) range
+// when header is empty.
+func parseRange(header string, total uint64) (start, end uint64, partial bool, err error) {
+	if header == "" {
+		return 0, total, false, nil
+	}
+
+	spec, ok := strings.CutPrefix(header, "bytes=")
+	if !ok || strings.Contains(spec, ",") {
+		return 0, 0, false, fmt.Errorf("unsupported range header %q", header)
+	}
+
+	bounds := strings.SplitN(spec, "-", 2)
+	if len(bounds) != 2 {
+		return 0, 0, false, fmt.Errorf("malformed range header %q", header)
+	}
+
+	switch {
+	case bounds[0] == "":
+		suffix, perr := strconv.ParseUint(bounds[1], 10, 64)
+		if perr != nil {
+			return 0, 0, false, fmt.Errorf("malformed range header %q", header)
+		}
+
+		if suffix > total {
+			suffix = total
+		}
+
+		return total - suffix, total, true, nil
+	default:
+		s, perr := strconv.ParseUint(bounds[0], 10, 64)
+		if perr != nil {
+			return 0, 0, false, fmt.Errorf("malformed range header %q", header)
+		}
+
+		e := total - 1
+
+		if bounds[1] != "" {
+			e, perr = strconv.ParseUint(bounds[1], 10, 64)
+			if perr != nil {
+				return 0, 0, false, fmt.Errorf("malformed range header %q", header)
+			}
+		}
+
+		if s >= total || e < s {
+			return 0, 0, false, fmt.Errorf("range %q not satisfiable for size %d", header, total)
+		}
+
+		if e >= total {
+			e = total - 1
+		}
+
+		return s, e + 1, true, nil
+	}
+}
+
+// openRange returns a reader over the bytes [start, end) of resource's
+// original file. It copies directly from the storage servers shard by
+// shard when every shard needed for the range is available, and falls back
+// to fetching the resource's full shard set and erasure-reconstructing it
+// when up to resource.ParityShards of them are missing.
+func (a *app) openRange(resource metadata.Resource, start, end uint64) (io.ReadCloser, error) {
+	shardSize := uint64(resource.ShardSize)
+	firstShard := int(start / shardSize)
+	lastShard := int((end - 1) / shardSize)
+
+	readers, missing := a.openShards(resource, firstShard, lastShard)
+
+	if len(missing) == 0 {
+		return &shardRangeReader{
+			readers: readers,
+			start:   start - uint64(firstShard)*shardSize,
+			end:     end - uint64(firstShard)*shardSize,
+		}, nil
+	}
+
+	for _, rc := range readers {
+		if rc != nil {
+			_ = rc.Close()
+		}
+	}
+
+	if len(missing) > resource.ParityShards {
+		return nil, errTooManyMissingShards
+	}
+
+	content, err := a.reconstruct(resource)
+	if err != nil {
+		return nil, err
+	}
+
+	if sum := sha256.Sum256(content); sum != resource.WholeSHA256 {
+		return nil, fmt.Errorf("whole file checksum mismatch after reconstruction: got %x, want %x",
+			sum, resource.WholeSHA256)
+	}
+
+	return io.NopCloser(bytes.NewReader(content[start:end])), nil
+}
+
+// openShards concurrently opens the chunks for shards [first, last] and
+// returns one reader per shard in order (nil where the fetch failed),
+// alongside the indices, relative to first, that failed.
+func (a *app) openShards(resource metadata.Resource, first, last int) ([]io.ReadCloser, []int) {
+	readers := make([]io.ReadCloser, last-first+1)
+
+	var (
+		mu      sync.Mutex
+		missing []int
+		wg      sync.WaitGroup
+	)
+
+	for i := first; i <= last; i++ {
+		i := i
+		info := resource.Chunks[i]
+
+		wg.Add(1)
+
+		go func() {
+			defer wg.Done()
+
+			srv := a.servers[int(info.Order)%len(a.servers)]
+
+			rc, _, err := srv.GetChunk(resource.ID, info.Order)
+
+			mu.Lock()
+			defer mu.Unlock()
+
+			if err != nil {
+				missing = append(missing, i-first)
+
+				return
+			}
+
+			readers[i-first] = rc
+		}()
+	}
+
+	wg.Wait()
+
+	return readers, missing
+}
+
+// reconstruct fetches every shard of resource and decodes the original
+// file via erasure coding, tolerating up to resource.ParityShards missing
+// shards. It is only used once a shard needed for a streamed range turns
+// out to be unavailable.
+func (a *app) reconstruct(resource metadata.Resource) ([]byte, error) {
+	readers, missing := a.openShards(resource, 0, len(resource.Chunks)-1)
+
+	if len(missing) > resource.ParityShards {
+		for _, rc := range readers {
+			if rc != nil {
+				_ = rc.Close()
+			}
+		}
+
+		return nil, errTooManyMissingShards
+	}
+
+	shards := make([][]byte, len(readers))
+
+	for i, rc := range readers {
+		if rc == nil {
+			continue
+		}
+
+		data, err := io.ReadAll(rc)
+
+		_ = rc.Close()
+
+		if err != nil {
+			return nil, fmt.Errorf("read shard %d: %w", i, err)
+		}
+
+		shards[i] = data
+	}
+
+	content, err := a.coder.Decode(shards, missing)
+	if err != nil {
+		return nil, fmt.Errorf("reconstruct missing shards: %w", err)
+	}
+
+	if uint64(len(content)) > resource.OriginalSize {
+		content = content[:resource.OriginalSize]
+	}
+
+	return content, nil
+}
+
+// shardRangeReader streams bytes [start, end) from a sequence of shard
+// readers concatenated end to end, where start/end are relative to the
+// first reader's offset 0. It skips straight to start without buffering the
+// skipped bytes, and stops once end is reached.
+type shardRangeReader struct {
+	readers    []io.ReadCloser
+	start, end uint64
+
+	pos     uint64
+	idx     int
+	skipped bool
+}
+
+func (s *shardRangeReader) Read(p []byte) (int, error) {
+	if s.pos >= s.end {
+		return 0, io.EOF
+	}
+
+	for !s.skipped {
+		toSkip := s.start - s.pos
+		if toSkip == 0 {
+			s.skipped = true
+
+			break
+		}
+
+		n, err := io.CopyN(io.Discard, s.readers[s.idx], int64(toSkip))
+		s.pos += uint64(n)
+
+		if err != nil {
+			// CopyN reports a source that ran out before n bytes were copied
+			// as io.EOF; that (or ErrUnexpectedEOF) means this reader is
+			// exhausted and skipping should continue from the next one.
+			if errors.Is(err, io.EOF) || errors.Is(err, io.ErrUnexpectedEOF) {
+				s.idx++
+
+				continue
+			}
+
+			return 0, err
+		}
+	}
+
+	if s.pos >= s.end {
+		return 0, io.EOF
+	}
+
+	remaining := s.end - s.pos
+	if uint64(len(p)) > remaining {
+		p = p[:remaining]
+	}
+
+	for s.idx < len(s.readers) {
+		n, err := s.readers[s.idx].Read(p)
+		s.pos += uint64(n)
+
+		if n > 0 {
+			return n, nil
+		}
+
+		if err != nil {
+			if errors.Is(err, io.EOF) {
+				s.idx++
+
+				continue
+			}
+
+			return 0, err
+		}
+	}
+
+	return 0, io.EOF
+}
+
+func (s *shardRangeReader) Close() error {
+	var firstErr error
+
+	for _, rc := range s.readers {
+		if err := rc.Close(); err != nil && firstErr == nil {
+			firstErr = err
+		}
+	}
+
+	return firstErr
+}
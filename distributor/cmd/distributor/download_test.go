@@ -0,0 +1,100 @@
+package main
+
+import (
+	"bytes"
+	"io"
+	"testing"
+)
+
+func TestParseRange(t *testing.T) {
+	const total = 100
+
+	tests := []struct {
+		name      string
+		header    string
+		wantStart uint64
+		wantEnd   uint64
+		wantPart  bool
+		wantErr   bool
+	}{
+		{name: "no range", header: "", wantStart: 0, wantEnd: total, wantPart: false},
+		{name: "prefix range", header: "bytes=10-19", wantStart: 10, wantEnd: 20, wantPart: true},
+		{name: "open ended range", header: "bytes=90-", wantStart: 90, wantEnd: total, wantPart: true},
+		{name: "suffix range", header: "bytes=-10", wantStart: 90, wantEnd: total, wantPart: true},
+		{name: "end clamped to total", header: "bytes=95-1000", wantStart: 95, wantEnd: total, wantPart: true},
+		{name: "malformed bounds", header: "bytes=abc-def", wantErr: true},
+		{name: "multiple ranges unsupported", header: "bytes=0-1,2-3", wantErr: true},
+		{name: "start beyond total", header: "bytes=1000-1010", wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			start, end, partial, err := parseRange(tt.header, total)
+
+			if tt.wantErr {
+				if err == nil {
+					t.Fatalf("parseRange(%q) = nil error, want error", tt.header)
+				}
+
+				return
+			}
+
+			if err != nil {
+				t.Fatalf("parseRange(%q) unexpected error: %v", tt.header, err)
+			}
+
+			if start != tt.wantStart || end != tt.wantEnd || partial != tt.wantPart {
+				t.Fatalf("parseRange(%q) = (%d, %d, %v), want (%d, %d, %v)",
+					tt.header, start, end, partial, tt.wantStart, tt.wantEnd, tt.wantPart)
+			}
+		})
+	}
+}
+
+func TestShardRangeReader(t *testing.T) {
+	shards := [][]byte{
+		[]byte("0123456789"),
+		[]byte("abcdefghij"),
+		[]byte("ABCDEFGHIJ"),
+	}
+
+	newReaders := func() []io.ReadCloser {
+		readers := make([]io.ReadCloser, len(shards))
+		for i, shard := range shards {
+			readers[i] = io.NopCloser(bytes.NewReader(shard))
+		}
+
+		return readers
+	}
+
+	tests := []struct {
+		name       string
+		start, end uint64
+		want       string
+	}{
+		{name: "whole range", start: 0, end: 30, want: "0123456789abcdefghijABCDEFGHIJ"},
+		{name: "within first shard", start: 2, end: 5, want: "234"},
+		{name: "spans a shard boundary", start: 8, end: 13, want: "89abc"},
+		{name: "last shard only", start: 20, end: 30, want: "ABCDEFGHIJ"},
+		{name: "empty range at a boundary", start: 10, end: 10, want: ""},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := &shardRangeReader{readers: newReaders(), start: tt.start, end: tt.end}
+
+			got, err := io.ReadAll(r)
+			if err != nil {
+				t.Fatalf("ReadAll: %v", err)
+			}
+
+			if string(got) != tt.want {
+				t.Fatalf("got %q, want %q", got, tt.want)
+			}
+
+			if err := r.Close(); err != nil {
+				t.Fatalf("Close: %v", err)
+			}
+		})
+	}
+}
@@ -1,18 +1,47 @@
 package main
 
 import (
+	"context"
 	"crypto/sha256"
 	"encoding/json"
 	"fmt"
+	"io"
+	"net/http"
+
 	"github.com/google/uuid"
+	"github.com/obalunenko/file-distributor/distributor/internal/erasure"
+	"github.com/obalunenko/file-distributor/distributor/internal/metadata"
+	"github.com/obalunenko/file-distributor/distributor/internal/tus"
 	log "github.com/obalunenko/logger"
 	"golang.org/x/sync/errgroup"
-	"io"
-	"net/http"
-	"strings"
-	"sync"
 )
 
+// app holds the dependencies the HTTP handlers need: the shard clients
+// files are distributed to, the erasure coder shards are encoded with, and
+// the store resource metadata is persisted in. Handlers are methods on app
+// instead of free functions so tests (and main) can wire up whichever
+// implementations they need.
+type app struct {
+	servers []StorageClient
+	addrs   []string
+	meta    metadata.Store
+	coder   erasure.Coder
+
+	dataShards   int
+	parityShards int
+}
+
+func newApp(servers []StorageClient, addrs []string, meta metadata.Store, coder erasure.Coder, dataShards, parityShards int) *app {
+	return &app{
+		servers:      servers,
+		addrs:        addrs,
+		meta:         meta,
+		coder:        coder,
+		dataShards:   dataShards,
+		parityShards: parityShards,
+	}
+}
+
 type response struct {
 	Message string `json:"message"`
 	Payload any    `json:"payload,omitempty"`
@@ -23,7 +52,88 @@ type uploadResponse struct {
 	Checksum string `json:"checksum"`
 }
 
-func uploadHandler(w http.ResponseWriter, r *http.Request) {
+// distributeFile erasure-codes data into dataShards+parityShards shards and
+// fans them out round-robin across the storage servers, registering the
+// chunk locations and coding layout under a freshly generated resource ID.
+// It is shared by the multipart uploadHandler, the chunkUploadHandler, and
+// the tus resumable upload handler so every upload path distributes files
+// the same way.
+func (a *app) distributeFile(ctx context.Context, filename string, data []byte) (string, error) {
+	shards, err := a.coder.Encode(data)
+	if err != nil {
+		return "", fmt.Errorf("encode shards: %w", err)
+	}
+
+	resourceID := generateResourceID()
+
+	chunks := make([]metadata.ChunkLocation, len(shards))
+
+	uploadFn := func(id int, shard []byte) error {
+		partName := buildPartName(filename, id)
+
+		srvIdx := id % len(a.servers)
+		srv := a.servers[srvIdx]
+
+		fmt.Printf("Sending shard %s to server %d \n", partName, srvIdx)
+
+		expected := sha256.Sum256(shard)
+
+		confirmed, err := srv.SaveChunk(resourceID, uint(id), shard)
+		if err != nil {
+			return err
+		}
+
+		if confirmed != expected {
+			return fmt.Errorf("shard %d digest mismatch after save: got %x, want %x", id, confirmed, expected)
+		}
+
+		chunks[id] = metadata.ChunkLocation{
+			Order:      uint(id),
+			ServerAddr: a.addrs[srvIdx],
+			Size:       uint64(len(shard)),
+			SHA256:     confirmed,
+		}
+
+		return nil
+	}
+
+	g, _ := errgroup.WithContext(ctx)
+
+	for i := range shards {
+		i, shard := i, shards[i]
+
+		g.Go(func() error {
+			if err := uploadFn(i, shard); err != nil {
+				return fmt.Errorf("failed to upload shard %d to server: %w", i, err)
+			}
+
+			return nil
+		})
+	}
+
+	if err := g.Wait(); err != nil {
+		return "", err
+	}
+
+	resource := metadata.Resource{
+		ID:           resourceID,
+		FileName:     filename,
+		Chunks:       chunks,
+		DataShards:   a.dataShards,
+		ParityShards: a.parityShards,
+		ShardSize:    len(shards[0]),
+		OriginalSize: uint64(len(data)),
+		WholeSHA256:  sha256.Sum256(data),
+	}
+
+	if err := a.meta.PutResource(ctx, resource); err != nil {
+		return "", fmt.Errorf("failed to persist resource metadata: %w", err)
+	}
+
+	return resourceID, nil
+}
+
+func (a *app) uploadHandler(w http.ResponseWriter, r *http.Request) {
 	if r.Method != http.MethodPost {
 		http.Error(w, "Only POST is supported", http.StatusMethodNotAllowed)
 
@@ -66,58 +176,8 @@ func uploadHandler(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	// Делим файл на части
-	parts := splitFile(fileBytes)
-
-	filename := fh.Filename
-
-	resourceID := generateResourceID()
-
-	uploadFn := func(mx sync.Locker, filename string, id int, data []byte) error {
-		partName := buildPartName(filename, id)
-
-		srv := servers[id]
-
-		mx.Lock()
-
-		chunks := fileChunksDB[resourceID]
-
-		chunks = append(chunks, fileChunkInfo{
-			ResourceID: resourceID,
-			FileName:   filename,
-			Order:      uint(id),
-			URL:        "TBD",
-		})
-
-		fileChunksDB[resourceID] = chunks
-
-		mx.Unlock()
-
-		fmt.Printf("Sending part %s to server %d \n", partName, id)
-
-		return srv.SaveChunk(resourceID, uint(id), data)
-	}
-
-	dbMutex.Lock()
-	fileChunksDB[resourceID] = make([]fileChunkInfo, 0, len(parts))
-	dbMutex.Unlock()
-
-	g, _ := errgroup.WithContext(r.Context())
-
-	// Отправляем каждую часть на сервер B
-	for i := range parts {
-		part := parts[i]
-
-		g.Go(func() error {
-			if err = uploadFn(dbMutex, filename, i, part); err != nil {
-				return fmt.Errorf("failed to upload part %d to server: %w", i, err)
-			}
-
-			return nil
-		})
-	}
-
-	if err = g.Wait(); err != nil {
+	resourceID, err := a.distributeFile(r.Context(), fh.Filename, fileBytes)
+	if err != nil {
 		http.Error(w, "Failed to upload file to storage", http.StatusInternalServerError)
 
 		return
@@ -147,7 +207,10 @@ func uploadHandler(w http.ResponseWriter, r *http.Request) {
 	}
 }
 
-func downloadHandler(w http.ResponseWriter, r *http.Request) {
+// manifestHandler serves the persisted Resource for a resource ID as JSON,
+// so a client can independently verify a download's whole-file and
+// per-chunk checksums.
+func (a *app) manifestHandler(w http.ResponseWriter, r *http.Request) {
 	if r.Method != http.MethodGet {
 		http.Error(w, "Only GET is supported", http.StatusMethodNotAllowed)
 
@@ -161,93 +224,66 @@ func downloadHandler(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	log.WithFields(r.Context(), log.Fields{
-		"resource_id": resourceID,
-	}).Info("Received download request")
+	resource, err := a.meta.GetResource(r.Context(), resourceID)
+	if err != nil {
+		if metadata.IsNotFound(err) {
+			http.Error(w, "File not found", http.StatusNotFound)
 
-	dbMutex.Lock()
-	chunks, ok := fileChunksDB[resourceID]
-	dbMutex.Unlock()
+			return
+		}
 
-	if !ok || len(chunks) == 0 {
-		http.Error(w, "File not found", http.StatusNotFound)
+		http.Error(w, "Failed to look up resource", http.StatusInternalServerError)
 
 		return
 	}
 
-	var (
-		buf      strings.Builder
-		fileName string
-	)
-
-	for i := range chunks {
-		if i == 0 {
-			fileName = chunks[i].FileName
-		}
-
-		info := chunks[i]
-
-		srv := servers[info.Order]
-
-		log.FromContext(r.Context()).WithFields(log.Fields{
-			"resource_id": resourceID,
-			"order":       info.Order,
-			"url":         info.URL,
-			"file_name":   info.FileName,
-		}).Info("Downloading file chunk")
-
-		ch, err := srv.GetChunk(resourceID)
-		if err != nil {
-			http.Error(w, "Failed to get file chunk", http.StatusInternalServerError)
-
-			return
-		}
+	w.Header().Set("Content-Type", "application/json")
 
-		log.WithFields(r.Context(), log.Fields{
-			"resource_id": resourceID,
-			"order":       info.Order,
-			"chunk_size":  len(ch.data),
-			"chunk":       ch.data[:10],
-		}).Info("File chunk downloaded successfully")
+	if err := json.NewEncoder(w).Encode(resource); err != nil {
+		fmt.Printf("Failed to encode manifest: %v \n", err)
 
-		n, err := buf.Write(ch.data)
-		if err != nil {
-			http.Error(w, "Failed to write file chunk", http.StatusInternalServerError)
+		http.Error(w, http.StatusText(http.StatusInternalServerError), http.StatusInternalServerError)
 
-			return
-		}
+		return
+	}
+}
 
-		if n != len(ch.data) {
-			http.Error(w, "Failed to write file chunk", http.StatusInternalServerError)
+// onTusUploadComplete is the tus.CompleteFunc wired up in main: once a
+// resumable upload has received all of its declared bytes, it is
+// distributed to the storage servers exactly like a multipart upload.
+func (a *app) onTusUploadComplete(ctx context.Context, upload tus.Upload, data []byte) (string, error) {
+	filename := upload.Metadata["filename"]
+	if filename == "" {
+		filename = upload.ID
+	}
 
-			return
-		}
+	resourceID, err := a.distributeFile(ctx, filename, data)
+	if err != nil {
+		return "", fmt.Errorf("failed to distribute tus upload %q: %w", upload.ID, err)
 	}
 
-	log.WithFields(r.Context(), log.Fields{
+	log.WithFields(ctx, log.Fields{
+		"file_name":   filename,
 		"resource_id": resourceID,
-	}).Info("File downloaded successfully")
-
-	w.Header().Set("Content-Disposition", fmt.Sprintf("attachment; filename=%s", fileName))
-	w.Header().Set("Content-Type", "application/octet-stream")
+	}).Info("Tus upload distributed successfully")
 
-	content := buf.String()
-
-	w.Header().Set("Content-Length", fmt.Sprintf("%d", len(content)))
-
-	log.WithFields(r.Context(), log.Fields{
-		"resource_id":    resourceID,
-		"content_length": len(content),
-		"content":        content[:10],
-	}).Info("Content of  downloaded file")
-
-	if _, err := w.Write([]byte(content)); err != nil {
-		fmt.Printf("Failed to write response: %v \n", err)
+	return resourceID, nil
+}
 
-		http.Error(w, http.StatusText(http.StatusInternalServerError), http.StatusInternalServerError)
+// tusRootHandler answers the tus discovery OPTIONS request on the server
+// root; any other method falls through to a plain 404, matching the
+// ServeMux's existing default behaviour for unregistered routes.
+func tusRootHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodOptions {
+		http.NotFound(w, r)
 
 		return
 	}
+
+	w.Header().Set("Tus-Resumable", tus.ResumableVersion)
+	w.Header().Set("Tus-Version", tus.ResumableVersion)
+	w.Header().Set("Tus-Extension", tus.SupportedExtensions)
+	w.WriteHeader(http.StatusNoContent)
 }
 
 func generateResourceID() string {
@@ -1,27 +1,54 @@
 package main
 
 import (
-	"bytes"
 	"context"
 	"errors"
+	"flag"
 	"fmt"
-	log "github.com/obalunenko/logger"
 	"io"
 	"net"
 	"net/http"
 	"os"
 	"os/signal"
+	"strconv"
+	"strings"
 	"sync"
 	"syscall"
+
+	"github.com/obalunenko/file-distributor/distributor/internal/erasure"
+	"github.com/obalunenko/file-distributor/distributor/internal/metadata"
+	"github.com/obalunenko/file-distributor/distributor/internal/tus"
+	log "github.com/obalunenko/logger"
 )
 
 const (
 	port = "8080"
 	name = "uploader"
+
+	tusUploadsDir = "tus-uploads"
+	tusBasePath   = "/files/"
+
+	envStorageAddresses = "STORAGE_ADDRESSES"
+	envMetadataDBPath   = "METADATA_DB_PATH"
+	envDataShards       = "DATA_SHARDS"
+	envParityShards     = "PARITY_SHARDS"
+
+	defaultMetadataDBPath = "metadata.db"
+	defaultDataShards     = 4
+	defaultParityShards   = 2
 )
 
 var errSignal = errors.New("received signal")
 
+var defaultAddresses = []string{
+	"http://localhost:8081",
+	"http://localhost:8082",
+	"http://localhost:8083",
+	"http://localhost:8084",
+	"http://localhost:8085",
+	"http://localhost:8086",
+}
+
 func main() {
 	l := log.FromContext(context.Background())
 
@@ -63,15 +90,67 @@ func main() {
 		cancel(fmt.Errorf("%w: %s", errSignal, s.String()))
 	}()
 
-	servers = make([]StorageClient, 0, len(addresses))
+	metadataDBPath := flag.String("metadata-db", lookupEnvOrDefault(envMetadataDBPath, defaultMetadataDBPath),
+		"path to the BoltDB file storing resource metadata")
+
+	dataShards := flag.Int("data-shards", lookupEnvIntOrDefault(envDataShards, defaultDataShards),
+		"number of data shards to split each upload into")
+
+	parityShards := flag.Int("parity-shards", lookupEnvIntOrDefault(envParityShards, defaultParityShards),
+		"number of parity shards to generate for each upload")
+
+	flag.Parse()
+
+	meta, err := metadata.NewBoltStore(*metadataDBPath)
+	if err != nil {
+		cancel(fmt.Errorf("failed to init metadata store: %w", err))
+
+		return
+	}
+
+	defer func() {
+		if cerr := meta.Close(); cerr != nil {
+			log.WithError(ctx, cerr).Error("Failed to close metadata store")
+		}
+	}()
+
+	coder, err := erasure.New(*dataShards, *parityShards)
+	if err != nil {
+		cancel(fmt.Errorf("failed to init erasure coder: %w", err))
+
+		return
+	}
+
+	addrs := storageAddresses()
+
+	servers := make([]StorageClient, 0, len(addrs))
 
-	for _, addr := range addresses {
+	for _, addr := range addrs {
 		servers = append(servers, newStorageClient(addr))
 	}
 
+	app := newApp(servers, addrs, meta, coder, *dataShards, *parityShards)
+
+	tusStore, err := tus.NewDiskStore(tusUploadsDir)
+	if err != nil {
+		cancel(fmt.Errorf("failed to init tus storage: %w", err))
+
+		return
+	}
+
+	tusHandler := &tus.Handler{
+		BasePath:   tusBasePath,
+		Store:      tusStore,
+		OnComplete: app.onTusUploadComplete,
+	}
+
 	mux := http.NewServeMux()
-	mux.HandleFunc("/upload", uploadHandler)
-	mux.HandleFunc("/download", downloadHandler)
+	mux.HandleFunc("/upload", app.uploadHandler)
+	mux.HandleFunc("/chunk-upload", app.chunkUploadHandler)
+	mux.HandleFunc("/download", app.downloadHandler)
+	mux.HandleFunc("/manifest", app.manifestHandler)
+	mux.Handle(tusBasePath, tusHandler)
+	mux.HandleFunc("/", tusRootHandler)
 
 	addr := net.JoinHostPort("", port)
 
@@ -111,125 +190,69 @@ func main() {
 	wg.Wait()
 }
 
-type fileChunkInfo struct {
-	ResourceID string
-	FileName   string
-	Order      uint
-	URL        string
-}
-
-var (
-	fileChunksDB = make(map[string][]fileChunkInfo)
-	dbMutex      = &sync.Mutex{}
-)
-
-func buildPartName(filename string, part int) string {
-	return fmt.Sprintf("%s-part-%d", filename, part)
-}
-
-func newStorageClient(addr string) StorageClient {
-	fmt.Printf("Connecting to server %s \n", addr)
-
-	return &mockStorageServer{
-		addr:    addr,
-		storage: make(map[string]chunk),
+// storageAddresses returns the configured shard server addresses, reading a
+// comma separated list from STORAGE_ADDRESSES if set, falling back to the
+// built-in local defaults otherwise.
+func storageAddresses() []string {
+	raw := os.Getenv(envStorageAddresses)
+	if raw == "" {
+		return defaultAddresses
 	}
-}
 
-var addresses = []string{
-	"http://localhost:8081",
-	"http://localhost:8082",
-	"http://localhost:8083",
-	"http://localhost:8084",
-	"http://localhost:8085",
-	"http://localhost:8086",
-}
+	parts := strings.Split(raw, ",")
 
-var servers []StorageClient
-
-type StorageClient interface {
-	SaveChunk(name string, order uint, data []byte) error
-	GetChunk(name string) (chunk, error)
-}
-
-type chunk struct {
-	order uint
-	data  []byte
-}
-
-type storageServer struct {
-	addr string
-}
-
-func (s *storageServer) SaveChunk(name string, order uint, data []byte) error {
-	url := fmt.Sprintf("%s/save-chunk?name=%s&order=%s&size=%d", s.addr, name, order, len(data))
-
-	resp, err := http.Post(url, "application/octet-stream", bytes.NewReader(data))
-	if err != nil {
-		return err
-	}
-
-	defer func() {
-		if err = resp.Body.Close(); err != nil {
-			fmt.Printf("Failed to close response body: %v \n", err)
-
-			return
-		}
-	}()
-
-	if resp.StatusCode != http.StatusOK {
-		body, err := io.ReadAll(resp.Body)
-		if err != nil {
+	addrs := make([]string, 0, len(parts))
 
+	for _, p := range parts {
+		p = strings.TrimSpace(p)
+		if p != "" {
+			addrs = append(addrs, p)
 		}
-
-		return fmt.Errorf("failed to store on server B: %s", body)
 	}
 
-	return nil
-}
-
-type mockStorageServer struct {
-	addr    string
-	storage map[string]chunk
+	return addrs
 }
 
-func (s *mockStorageServer) GetChunk(name string) (chunk, error) {
-	fmt.Printf("Getting resource %q from server %s \n", name, s.addr)
-
-	ch, ok := s.storage[name]
-	if !ok {
-		return chunk{}, fmt.Errorf("resource %q not found", name)
+func lookupEnvOrDefault(key, def string) string {
+	if v, ok := os.LookupEnv(key); ok && v != "" {
+		return v
 	}
 
-	return ch, nil
+	return def
 }
 
-func (s *mockStorageServer) SaveChunk(name string, order uint, data []byte) error {
-	fmt.Printf("Storing resource %q chunk order %d on server %s \n", name, order, s.addr)
+func lookupEnvIntOrDefault(key string, def int) int {
+	v, ok := os.LookupEnv(key)
+	if !ok || v == "" {
+		return def
+	}
 
-	s.storage[name] = chunk{
-		order: order,
-		data:  data,
+	n, err := strconv.Atoi(v)
+	if err != nil {
+		return def
 	}
 
-	return nil
+	return n
 }
 
-func splitFile(file []byte) [][]byte {
-	parts := len(servers)
-
-	partSize := len(file) / parts
+func buildPartName(filename string, part int) string {
+	return fmt.Sprintf("%s-part-%d", filename, part)
+}
 
-	splitted := make([][]byte, parts)
+func newStorageClient(addr string) StorageClient {
+	fmt.Printf("Connecting to server %s \n", addr)
 
-	for i := 0; i < parts; i++ {
-		if i == parts-1 {
-			splitted[i] = file[i*partSize:]
-		} else {
-			splitted[i] = file[i*partSize : (i+1)*partSize]
-		}
-	}
+	return &storageServer{addr: addr}
+}
 
-	return splitted
+type StorageClient interface {
+	// SaveChunk stores data under name/order and returns the SHA-256 digest
+	// the server confirms it received, so the caller can detect corruption
+	// introduced in transit.
+	SaveChunk(name string, order uint, data []byte) (sha256Sum [32]byte, err error)
+
+	// GetChunk opens the chunk stored under name/order for reading without
+	// materializing it, returning its size so callers can size buffers or
+	// set Content-Length. The caller must close the returned reader.
+	GetChunk(name string, order uint) (r io.ReadCloser, size int64, err error)
 }
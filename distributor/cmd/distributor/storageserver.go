@@ -0,0 +1,95 @@
+package main
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"net/http"
+)
+
+// headerChunkSHA256 carries the SHA-256 digest of a chunk's bytes between
+// the uploader and a shard node, so corruption introduced in transit can be
+// detected on both ends.
+const headerChunkSHA256 = "X-Chunk-SHA256"
+
+// storageServer is a StorageClient that talks to a real shard node over
+// HTTP, POSTing chunks to /save-chunk and fetching them back from
+// /get-chunk.
+type storageServer struct {
+	addr string
+}
+
+// SaveChunk implements StorageClient.
+func (s *storageServer) SaveChunk(name string, order uint, data []byte) ([32]byte, error) {
+	sum := sha256.Sum256(data)
+
+	url := fmt.Sprintf("%s/save-chunk?name=%s&order=%d&size=%d", s.addr, name, order, len(data))
+
+	req, err := http.NewRequest(http.MethodPost, url, bytes.NewReader(data))
+	if err != nil {
+		return [32]byte{}, fmt.Errorf("build save-chunk request for %s: %w", s.addr, err)
+	}
+
+	req.Header.Set("Content-Type", "application/octet-stream")
+	req.Header.Set(headerChunkSHA256, hex.EncodeToString(sum[:]))
+
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return [32]byte{}, fmt.Errorf("save chunk on %s: %w", s.addr, err)
+	}
+
+	defer func() {
+		if cerr := resp.Body.Close(); cerr != nil {
+			fmt.Printf("Failed to close response body: %v \n", cerr)
+		}
+	}()
+
+	if resp.StatusCode != http.StatusOK {
+		body, _ := io.ReadAll(resp.Body)
+
+		return [32]byte{}, fmt.Errorf("failed to store chunk on %s: %s", s.addr, body)
+	}
+
+	h := resp.Header.Get(headerChunkSHA256)
+	if h == "" {
+		return [32]byte{}, fmt.Errorf("shard server %s did not confirm a digest for the stored chunk", s.addr)
+	}
+
+	decoded, err := hex.DecodeString(h)
+	if err != nil || len(decoded) != sha256.Size {
+		return [32]byte{}, fmt.Errorf("shard server %s returned a malformed confirmation digest %q", s.addr, h)
+	}
+
+	var confirmed [32]byte
+
+	copy(confirmed[:], decoded)
+
+	return confirmed, nil
+}
+
+// GetChunk implements StorageClient. name identifies the resource and order
+// identifies which of its (possibly several) shards stored on this server to
+// fetch. The response body is returned unread so the caller can stream it
+// without buffering the whole shard in memory; the caller must close it.
+func (s *storageServer) GetChunk(name string, order uint) (io.ReadCloser, int64, error) {
+	url := fmt.Sprintf("%s/get-chunk?name=%s&order=%d", s.addr, name, order)
+
+	resp, err := http.Get(url)
+	if err != nil {
+		return nil, 0, fmt.Errorf("get chunk from %s: %w", s.addr, err)
+	}
+
+	if resp.StatusCode != http.StatusOK {
+		defer func() {
+			_ = resp.Body.Close()
+		}()
+
+		body, _ := io.ReadAll(resp.Body)
+
+		return nil, 0, fmt.Errorf("failed to get chunk from %s: %s", s.addr, body)
+	}
+
+	return resp.Body, resp.ContentLength, nil
+}
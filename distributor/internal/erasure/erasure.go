@@ -0,0 +1,96 @@
+// Package erasure encodes files into erasure-coded shards so that losing a
+// handful of storage servers does not lose the file.
+package erasure
+
+import (
+	"bytes"
+	"fmt"
+
+	"github.com/klauspost/reedsolomon"
+)
+
+// Coder splits data into redundant shards and reconstructs data from a
+// subset of them.
+type Coder interface {
+	// Encode splits data into dataShards+parityShards shards, the last of
+	// which may be zero-padded, such that the original data can be
+	// recovered from any dataShards of them.
+	Encode(data []byte) (shards [][]byte, err error)
+
+	// Decode reconstructs the original data from shards, given the indices
+	// in missing that are absent (nil) in shards. It returns the
+	// zero-padded data; callers should trim it to the known original size.
+	Decode(shards [][]byte, missing []int) ([]byte, error)
+}
+
+// ReedSolomon is a Coder backed by Reed-Solomon erasure coding.
+type ReedSolomon struct {
+	dataShards   int
+	parityShards int
+	enc          reedsolomon.Encoder
+}
+
+// New returns a ReedSolomon Coder configured for dataShards data shards and
+// parityShards parity shards, tolerating the loss of up to parityShards of
+// the dataShards+parityShards shards produced by Encode.
+func New(dataShards, parityShards int) (*ReedSolomon, error) {
+	enc, err := reedsolomon.New(dataShards, parityShards)
+	if err != nil {
+		return nil, fmt.Errorf("create reed-solomon encoder: %w", err)
+	}
+
+	return &ReedSolomon{
+		dataShards:   dataShards,
+		parityShards: parityShards,
+		enc:          enc,
+	}, nil
+}
+
+// DataShards returns the number of data shards data is split into.
+func (c *ReedSolomon) DataShards() int {
+	return c.dataShards
+}
+
+// ParityShards returns the number of redundant shards produced alongside
+// the data shards.
+func (c *ReedSolomon) ParityShards() int {
+	return c.parityShards
+}
+
+// Encode implements Coder.
+func (c *ReedSolomon) Encode(data []byte) ([][]byte, error) {
+	shards, err := c.enc.Split(data)
+	if err != nil {
+		return nil, fmt.Errorf("split data into shards: %w", err)
+	}
+
+	if err := c.enc.Encode(shards); err != nil {
+		return nil, fmt.Errorf("encode parity shards: %w", err)
+	}
+
+	return shards, nil
+}
+
+// Decode implements Coder.
+func (c *ReedSolomon) Decode(shards [][]byte, missing []int) ([]byte, error) {
+	working := make([][]byte, len(shards))
+	copy(working, shards)
+
+	for _, idx := range missing {
+		working[idx] = nil
+	}
+
+	if err := c.enc.Reconstruct(working); err != nil {
+		return nil, fmt.Errorf("reconstruct missing shards: %w", err)
+	}
+
+	var buf bytes.Buffer
+
+	for _, shard := range working[:c.dataShards] {
+		buf.Write(shard)
+	}
+
+	return buf.Bytes(), nil
+}
+
+var _ Coder = (*ReedSolomon)(nil)
@@ -0,0 +1,67 @@
+package erasure
+
+import (
+	"bytes"
+	"testing"
+)
+
+func TestReedSolomonEncodeDecodeRoundTrip(t *testing.T) {
+	coder, err := New(4, 2)
+	if err != nil {
+		t.Fatalf("New: %v", err)
+	}
+
+	data := bytes.Repeat([]byte("reed-solomon round trip "), 1000)
+
+	shards, err := coder.Encode(data)
+	if err != nil {
+		t.Fatalf("Encode: %v", err)
+	}
+
+	if got, want := len(shards), coder.DataShards()+coder.ParityShards(); got != want {
+		t.Fatalf("len(shards) = %d, want %d", got, want)
+	}
+
+	missing := []int{1, 4}
+
+	working := make([][]byte, len(shards))
+	copy(working, shards)
+
+	for _, idx := range missing {
+		working[idx] = nil
+	}
+
+	decoded, err := coder.Decode(working, missing)
+	if err != nil {
+		t.Fatalf("Decode: %v", err)
+	}
+
+	if got := decoded[:len(data)]; !bytes.Equal(got, data) {
+		t.Fatal("decoded data does not match the original after reconstructing missing shards")
+	}
+}
+
+func TestReedSolomonDecodeTooManyMissingShards(t *testing.T) {
+	coder, err := New(4, 2)
+	if err != nil {
+		t.Fatalf("New: %v", err)
+	}
+
+	shards, err := coder.Encode([]byte("short payload"))
+	if err != nil {
+		t.Fatalf("Encode: %v", err)
+	}
+
+	missing := []int{0, 1, 2}
+
+	working := make([][]byte, len(shards))
+	copy(working, shards)
+
+	for _, idx := range missing {
+		working[idx] = nil
+	}
+
+	if _, err := coder.Decode(working, missing); err == nil {
+		t.Fatal("Decode with more missing shards than parity allows returned nil error, want one")
+	}
+}
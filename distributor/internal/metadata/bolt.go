@@ -0,0 +1,97 @@
+package metadata
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+
+	"go.etcd.io/bbolt"
+)
+
+var resourcesBucket = []byte("resources")
+
+// BoltStore is a Store backed by a single BoltDB file.
+type BoltStore struct {
+	db *bbolt.DB
+}
+
+// NewBoltStore opens (creating if necessary) a BoltDB database at path and
+// ensures the resources bucket exists.
+func NewBoltStore(path string) (*BoltStore, error) {
+	db, err := bbolt.Open(path, 0o600, nil)
+	if err != nil {
+		return nil, fmt.Errorf("open bolt db: %w", err)
+	}
+
+	err = db.Update(func(tx *bbolt.Tx) error {
+		_, err := tx.CreateBucketIfNotExists(resourcesBucket)
+
+		return err
+	})
+	if err != nil {
+		return nil, fmt.Errorf("create resources bucket: %w", err)
+	}
+
+	return &BoltStore{db: db}, nil
+}
+
+// Close releases the underlying database file.
+func (s *BoltStore) Close() error {
+	return s.db.Close()
+}
+
+// PutResource implements Store.
+func (s *BoltStore) PutResource(_ context.Context, resource Resource) error {
+	data, err := json.Marshal(resource)
+	if err != nil {
+		return fmt.Errorf("marshal resource: %w", err)
+	}
+
+	return s.db.Update(func(tx *bbolt.Tx) error {
+		return tx.Bucket(resourcesBucket).Put([]byte(resource.ID), data)
+	})
+}
+
+// GetResource implements Store.
+func (s *BoltStore) GetResource(_ context.Context, id string) (Resource, error) {
+	var resource Resource
+
+	err := s.db.View(func(tx *bbolt.Tx) error {
+		data := tx.Bucket(resourcesBucket).Get([]byte(id))
+		if data == nil {
+			return ErrNotFound
+		}
+
+		return json.Unmarshal(data, &resource)
+	})
+	if err != nil {
+		return Resource{}, err
+	}
+
+	return resource, nil
+}
+
+// ListChunks implements Store.
+func (s *BoltStore) ListChunks(ctx context.Context, id string) ([]ChunkLocation, error) {
+	resource, err := s.GetResource(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+
+	return resource.Chunks, nil
+}
+
+// DeleteResource implements Store.
+func (s *BoltStore) DeleteResource(_ context.Context, id string) error {
+	return s.db.Update(func(tx *bbolt.Tx) error {
+		return tx.Bucket(resourcesBucket).Delete([]byte(id))
+	})
+}
+
+var _ Store = (*BoltStore)(nil)
+
+// IsNotFound reports whether err is (or wraps) ErrNotFound.
+func IsNotFound(err error) bool {
+	return errors.Is(err, ErrNotFound)
+}
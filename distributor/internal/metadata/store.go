@@ -0,0 +1,64 @@
+// Package metadata persists resource and chunk-placement metadata for files
+// distributed across storage servers, so that metadata survives a restart of
+// the uploader process.
+package metadata
+
+import (
+	"context"
+	"errors"
+)
+
+// ErrNotFound is returned when a resource does not exist in the Store.
+var ErrNotFound = errors.New("metadata: resource not found")
+
+// ChunkLocation records which server holds a given chunk of a resource, and
+// the digest it was stored with so corruption can be detected independently
+// of the whole-file checksum.
+type ChunkLocation struct {
+	Order      uint
+	ServerAddr string
+	Size       uint64
+	SHA256     [32]byte
+}
+
+// Resource is the manifest the uploader needs to locate, reassemble, and
+// verify a previously uploaded file.
+type Resource struct {
+	ID       string
+	FileName string
+	Chunks   []ChunkLocation
+
+	// DataShards and ParityShards record the erasure coding layout the file
+	// was split with, so a download can reconstruct missing shards and know
+	// how many chunk losses it can tolerate.
+	DataShards   int
+	ParityShards int
+
+	// ShardSize is the size in bytes of each encoded shard, data and parity
+	// alike (the tail of the data shards is zero-padded to fill it).
+	ShardSize int
+
+	// OriginalSize is the size of the file before encoding, used to trim
+	// the zero-padding added by the erasure coder.
+	OriginalSize uint64
+
+	// WholeSHA256 is the SHA-256 digest of the whole original file,
+	// independently verifiable by a client via GET /manifest.
+	WholeSHA256 [32]byte
+}
+
+// Store persists Resources so they can be looked up again after a restart.
+type Store interface {
+	// PutResource creates or replaces the resource and its chunk locations.
+	PutResource(ctx context.Context, resource Resource) error
+
+	// GetResource returns the resource with the given ID, or ErrNotFound.
+	GetResource(ctx context.Context, id string) (Resource, error)
+
+	// ListChunks returns the chunk locations for the resource with the
+	// given ID, or ErrNotFound.
+	ListChunks(ctx context.Context, id string) ([]ChunkLocation, error)
+
+	// DeleteResource removes the resource and its chunk locations.
+	DeleteResource(ctx context.Context, id string) error
+}
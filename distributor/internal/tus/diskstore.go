@@ -0,0 +1,226 @@
+package tus
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+
+	"github.com/google/uuid"
+)
+
+// metaSuffix names the sidecar file each upload's size and metadata are
+// persisted under, alongside the data file holding its bytes.
+const metaSuffix = ".meta"
+
+// uploadMeta is the subset of Upload that can't be recovered by statting the
+// data file, persisted to metaSuffix so it survives a process restart.
+type uploadMeta struct {
+	Size     int64
+	Metadata map[string]string
+}
+
+// DiskStore persists upload bytes in one file per upload plus a JSON sidecar
+// of its size and metadata, both on local disk, so an in-progress upload can
+// be resumed by a later PATCH even after the process restarts: NewDiskStore
+// rebuilds its in-memory state by scanning dir and statting each data file
+// for the offset already received.
+type DiskStore struct {
+	dir string
+
+	mu      sync.Mutex
+	uploads map[string]Upload
+}
+
+// NewDiskStore creates a DiskStore rooted at dir, creating the directory if
+// it does not already exist, and restores any uploads already on disk.
+func NewDiskStore(dir string) (*DiskStore, error) {
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return nil, fmt.Errorf("create tus storage dir: %w", err)
+	}
+
+	s := &DiskStore{
+		dir:     dir,
+		uploads: make(map[string]Upload),
+	}
+
+	if err := s.restore(); err != nil {
+		return nil, fmt.Errorf("restore tus uploads: %w", err)
+	}
+
+	return s, nil
+}
+
+// restore rebuilds s.uploads from the sidecar files left in dir by a
+// previous process, deriving each upload's offset from its data file size.
+func (s *DiskStore) restore() error {
+	entries, err := os.ReadDir(s.dir)
+	if err != nil {
+		return fmt.Errorf("read tus storage dir: %w", err)
+	}
+
+	for _, entry := range entries {
+		name := entry.Name()
+
+		id, ok := strings.CutSuffix(name, metaSuffix)
+		if !ok {
+			continue
+		}
+
+		raw, err := os.ReadFile(s.metaPath(id))
+		if err != nil {
+			return fmt.Errorf("read meta for upload %q: %w", id, err)
+		}
+
+		var meta uploadMeta
+
+		if err := json.Unmarshal(raw, &meta); err != nil {
+			return fmt.Errorf("decode meta for upload %q: %w", id, err)
+		}
+
+		info, err := os.Stat(s.path(id))
+		if err != nil {
+			return fmt.Errorf("stat data file for upload %q: %w", id, err)
+		}
+
+		s.uploads[id] = Upload{
+			ID:       id,
+			Size:     meta.Size,
+			Offset:   info.Size(),
+			Metadata: meta.Metadata,
+		}
+	}
+
+	return nil
+}
+
+func (s *DiskStore) path(id string) string {
+	return filepath.Join(s.dir, id)
+}
+
+func (s *DiskStore) metaPath(id string) string {
+	return filepath.Join(s.dir, id+metaSuffix)
+}
+
+// Create implements Store.
+func (s *DiskStore) Create(_ context.Context, size int64, metadata map[string]string) (Upload, error) {
+	id := uuid.New().String()
+
+	f, err := os.Create(s.path(id))
+	if err != nil {
+		return Upload{}, fmt.Errorf("create upload file: %w", err)
+	}
+
+	if err := f.Close(); err != nil {
+		return Upload{}, fmt.Errorf("close upload file: %w", err)
+	}
+
+	raw, err := json.Marshal(uploadMeta{Size: size, Metadata: metadata})
+	if err != nil {
+		return Upload{}, fmt.Errorf("encode upload meta: %w", err)
+	}
+
+	if err := os.WriteFile(s.metaPath(id), raw, 0o644); err != nil {
+		return Upload{}, fmt.Errorf("write upload meta: %w", err)
+	}
+
+	upload := Upload{
+		ID:       id,
+		Size:     size,
+		Offset:   0,
+		Metadata: metadata,
+	}
+
+	s.mu.Lock()
+	s.uploads[id] = upload
+	s.mu.Unlock()
+
+	return upload, nil
+}
+
+// Get implements Store.
+func (s *DiskStore) Get(_ context.Context, id string) (Upload, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	upload, ok := s.uploads[id]
+	if !ok {
+		return Upload{}, ErrNotFound
+	}
+
+	return upload, nil
+}
+
+// WriteChunk implements Store.
+func (s *DiskStore) WriteChunk(_ context.Context, id string, offset int64, data []byte) (int64, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	upload, ok := s.uploads[id]
+	if !ok {
+		return 0, ErrNotFound
+	}
+
+	if offset != upload.Offset {
+		return 0, ErrOffsetMismatch
+	}
+
+	f, err := os.OpenFile(s.path(id), os.O_WRONLY, 0o644)
+	if err != nil {
+		return 0, fmt.Errorf("open upload file: %w", err)
+	}
+
+	defer func() {
+		if cerr := f.Close(); cerr != nil {
+			fmt.Printf("Failed to close upload file %q: %v \n", id, cerr)
+		}
+	}()
+
+	n, err := f.WriteAt(data, offset)
+	if err != nil {
+		return 0, fmt.Errorf("write upload chunk: %w", err)
+	}
+
+	upload.Offset += int64(n)
+	s.uploads[id] = upload
+
+	return upload.Offset, nil
+}
+
+// ReadAll implements Store.
+func (s *DiskStore) ReadAll(_ context.Context, id string) ([]byte, error) {
+	s.mu.Lock()
+	_, ok := s.uploads[id]
+	s.mu.Unlock()
+
+	if !ok {
+		return nil, ErrNotFound
+	}
+
+	data, err := os.ReadFile(s.path(id))
+	if err != nil {
+		return nil, fmt.Errorf("read upload file: %w", err)
+	}
+
+	return data, nil
+}
+
+// Delete implements Store.
+func (s *DiskStore) Delete(_ context.Context, id string) error {
+	s.mu.Lock()
+	delete(s.uploads, id)
+	s.mu.Unlock()
+
+	if err := os.Remove(s.path(id)); err != nil && !os.IsNotExist(err) {
+		return fmt.Errorf("remove upload file: %w", err)
+	}
+
+	if err := os.Remove(s.metaPath(id)); err != nil && !os.IsNotExist(err) {
+		return fmt.Errorf("remove upload meta: %w", err)
+	}
+
+	return nil
+}
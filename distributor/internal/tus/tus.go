@@ -0,0 +1,300 @@
+// Package tus implements the server side of a minimal subset of the tus
+// 1.0.0 resumable upload protocol (https://tus.io/protocols/resumable-upload.html),
+// enough for large uploads to survive a dropped connection instead of
+// requiring the whole file in a single multipart POST.
+package tus
+
+import (
+	"context"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strconv"
+	"strings"
+)
+
+const (
+	// ResumableVersion is the protocol version this server implements.
+	ResumableVersion = "1.0.0"
+
+	// SupportedExtensions lists the tus extensions this server actually
+	// implements, advertised in the OPTIONS response: creation (POST to
+	// start an upload) and termination (DELETE to abandon one). Expiration
+	// and checksum are not implemented and must not be advertised here.
+	SupportedExtensions = "creation,termination"
+
+	headerUploadOffset   = "Upload-Offset"
+	headerUploadLength   = "Upload-Length"
+	headerUploadMetadata = "Upload-Metadata"
+	headerTusResumable   = "Tus-Resumable"
+	headerTusVersion     = "Tus-Version"
+	headerTusExtension   = "Tus-Extension"
+	headerContentType    = "Content-Type"
+	offsetContentType    = "application/offset+octet-stream"
+)
+
+var (
+	// ErrNotFound is returned by a Store when the requested upload is unknown.
+	ErrNotFound = errors.New("tus: upload not found")
+
+	// ErrOffsetMismatch is returned by a Store when a PATCH does not start at
+	// the upload's current offset.
+	ErrOffsetMismatch = errors.New("tus: offset mismatch")
+)
+
+// Upload is the persisted state of a single in-progress (or completed) upload.
+type Upload struct {
+	ID       string
+	Size     int64
+	Offset   int64
+	Metadata map[string]string
+}
+
+// Done reports whether all declared bytes have been received.
+func (u Upload) Done() bool {
+	return u.Offset >= u.Size
+}
+
+// Store persists upload state and bytes so that an interrupted upload can be
+// resumed by a later PATCH referencing the same ID.
+type Store interface {
+	// Create allocates storage for a new upload of the given size and
+	// returns the Upload with a freshly assigned ID.
+	Create(ctx context.Context, size int64, metadata map[string]string) (Upload, error)
+
+	// Get returns the current state of the upload with the given ID.
+	Get(ctx context.Context, id string) (Upload, error)
+
+	// WriteChunk appends data to the upload starting at offset, returning
+	// the upload's new offset. It returns ErrOffsetMismatch if offset does
+	// not equal the upload's current offset.
+	WriteChunk(ctx context.Context, id string, offset int64, data []byte) (int64, error)
+
+	// ReadAll returns the full contents received so far for the upload.
+	ReadAll(ctx context.Context, id string) ([]byte, error)
+
+	// Delete removes all state and bytes associated with the upload.
+	Delete(ctx context.Context, id string) error
+}
+
+// CompleteFunc is invoked once an upload reaches its declared size. It
+// receives the assembled bytes and the metadata supplied at creation, and
+// returns the resource ID the caller can later use to download the file.
+type CompleteFunc func(ctx context.Context, upload Upload, data []byte) (resourceID string, err error)
+
+// Handler serves the tus creation, head, and patch endpoints backed by a
+// Store, and hands completed uploads off to OnComplete.
+type Handler struct {
+	// BasePath is the URL path uploads are created under and addressed
+	// below, e.g. "/files/".
+	BasePath string
+
+	Store      Store
+	OnComplete CompleteFunc
+}
+
+// ServeHTTP dispatches creation, resume, and discovery requests for uploads
+// mounted under BasePath.
+func (h *Handler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set(headerTusResumable, ResumableVersion)
+
+	id := strings.TrimPrefix(r.URL.Path, h.BasePath)
+
+	switch {
+	case r.Method == http.MethodOptions:
+		h.options(w, r)
+	case r.Method == http.MethodPost && id == "":
+		h.create(w, r)
+	case r.Method == http.MethodHead && id != "":
+		h.head(w, r, id)
+	case r.Method == http.MethodPatch && id != "":
+		h.patch(w, r, id)
+	case r.Method == http.MethodDelete && id != "":
+		h.delete(w, r, id)
+	default:
+		http.Error(w, "unsupported tus request", http.StatusMethodNotAllowed)
+	}
+}
+
+// options answers the tus discovery request, advertising the supported
+// protocol version and extensions.
+func (h *Handler) options(w http.ResponseWriter, _ *http.Request) {
+	w.Header().Set(headerTusVersion, ResumableVersion)
+	w.Header().Set(headerTusExtension, SupportedExtensions)
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// create handles POST /files/, allocating a new upload and returning its
+// Location.
+func (h *Handler) create(w http.ResponseWriter, r *http.Request) {
+	size, err := strconv.ParseInt(r.Header.Get(headerUploadLength), 10, 64)
+	if err != nil || size < 0 {
+		http.Error(w, "invalid or missing Upload-Length", http.StatusBadRequest)
+
+		return
+	}
+
+	metadata, err := parseUploadMetadata(r.Header.Get(headerUploadMetadata))
+	if err != nil {
+		http.Error(w, fmt.Sprintf("invalid Upload-Metadata: %v", err), http.StatusBadRequest)
+
+		return
+	}
+
+	upload, err := h.Store.Create(r.Context(), size, metadata)
+	if err != nil {
+		http.Error(w, "failed to create upload", http.StatusInternalServerError)
+
+		return
+	}
+
+	w.Header().Set("Location", h.BasePath+upload.ID)
+	w.WriteHeader(http.StatusCreated)
+}
+
+// head handles HEAD /files/{id}, reporting how many bytes have been
+// received so a client can resume from the right offset.
+func (h *Handler) head(w http.ResponseWriter, r *http.Request, id string) {
+	upload, err := h.Store.Get(r.Context(), id)
+	if err != nil {
+		writeStoreError(w, err)
+
+		return
+	}
+
+	w.Header().Set(headerUploadOffset, strconv.FormatInt(upload.Offset, 10))
+	w.Header().Set(headerUploadLength, strconv.FormatInt(upload.Size, 10))
+	w.Header().Set("Cache-Control", "no-store")
+	w.WriteHeader(http.StatusOK)
+}
+
+// patch handles PATCH /files/{id}, appending the request body starting at
+// Upload-Offset and, once the upload is complete, handing it off to
+// OnComplete.
+func (h *Handler) patch(w http.ResponseWriter, r *http.Request, id string) {
+	if r.Header.Get(headerContentType) != offsetContentType {
+		http.Error(w, "Content-Type must be "+offsetContentType, http.StatusUnsupportedMediaType)
+
+		return
+	}
+
+	offset, err := strconv.ParseInt(r.Header.Get(headerUploadOffset), 10, 64)
+	if err != nil || offset < 0 {
+		http.Error(w, "invalid or missing Upload-Offset", http.StatusBadRequest)
+
+		return
+	}
+
+	body := http.MaxBytesReader(w, r.Body, 64<<20)
+
+	data, err := io.ReadAll(body)
+	if err != nil {
+		http.Error(w, "failed to read chunk", http.StatusBadRequest)
+
+		return
+	}
+
+	newOffset, err := h.Store.WriteChunk(r.Context(), id, offset, data)
+	if err != nil {
+		writeStoreError(w, err)
+
+		return
+	}
+
+	w.Header().Set(headerUploadOffset, strconv.FormatInt(newOffset, 10))
+
+	upload, err := h.Store.Get(r.Context(), id)
+	if err != nil {
+		writeStoreError(w, err)
+
+		return
+	}
+
+	if !upload.Done() {
+		w.WriteHeader(http.StatusNoContent)
+
+		return
+	}
+
+	final, err := h.Store.ReadAll(r.Context(), id)
+	if err != nil {
+		http.Error(w, "failed to assemble upload", http.StatusInternalServerError)
+
+		return
+	}
+
+	resourceID, err := h.OnComplete(r.Context(), upload, final)
+	if err != nil {
+		http.Error(w, "failed to distribute upload", http.StatusInternalServerError)
+
+		return
+	}
+
+	if err := h.Store.Delete(r.Context(), id); err != nil {
+		fmt.Printf("Failed to delete completed tus upload %q: %v \n", id, err)
+	}
+
+	w.Header().Set("X-Resource-Id", resourceID)
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// delete handles DELETE /files/{id}, implementing the termination extension
+// by abandoning an in-progress upload and freeing its storage.
+func (h *Handler) delete(w http.ResponseWriter, r *http.Request, id string) {
+	if err := h.Store.Delete(r.Context(), id); err != nil {
+		writeStoreError(w, err)
+
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func writeStoreError(w http.ResponseWriter, err error) {
+	switch {
+	case errors.Is(err, ErrNotFound):
+		http.Error(w, "upload not found", http.StatusNotFound)
+	case errors.Is(err, ErrOffsetMismatch):
+		http.Error(w, "conflict: unexpected Upload-Offset", http.StatusConflict)
+	default:
+		http.Error(w, "internal error", http.StatusInternalServerError)
+	}
+}
+
+// parseUploadMetadata decodes the tus Upload-Metadata header: a comma
+// separated list of "key base64(value)" pairs.
+func parseUploadMetadata(header string) (map[string]string, error) {
+	if header == "" {
+		return nil, nil
+	}
+
+	metadata := make(map[string]string)
+
+	for _, pair := range strings.Split(header, ",") {
+		pair = strings.TrimSpace(pair)
+		if pair == "" {
+			continue
+		}
+
+		parts := strings.SplitN(pair, " ", 2)
+
+		key := parts[0]
+
+		var value string
+
+		if len(parts) == 2 {
+			decoded, err := base64.StdEncoding.DecodeString(parts[1])
+			if err != nil {
+				return nil, fmt.Errorf("decode value for key %q: %w", key, err)
+			}
+
+			value = string(decoded)
+		}
+
+		metadata[key] = value
+	}
+
+	return metadata, nil
+}